@@ -0,0 +1,75 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command release_config is the command-line entry point for
+// release_config_lib. It currently wires up only the `diff` subcommand.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"android/soong/cmd/release_config/release_config_lib"
+)
+
+func diffMain(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	var mapPaths []string
+	fs.Func("map", "path to a release_config_map.textproto or .scl (repeatable)", func(s string) error {
+		mapPaths = append(mapPaths, s)
+		return nil
+	})
+	targetRelease := fs.String("release", "trunk_staging", "release config used to resolve defaults")
+	oldRelease := fs.String("old", "", "release config to diff from")
+	newRelease := fs.String("new", "", "release config to diff to")
+	outFile := fs.String("out", "", "output file")
+	format := fs.String("format", "text", "one of text, json, pb")
+	allowMissing := fs.Bool("allow-missing", false, "substitute trunk_staging for a missing release config")
+	allowUnknownNamespace := fs.Bool("allow-unknown-namespace", false, "allow a flag whose namespace is not registered")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *oldRelease == "" || *newRelease == "" {
+		return fmt.Errorf("--old and --new are required")
+	}
+	if *outFile == "" {
+		return fmt.Errorf("--out is required")
+	}
+	configs, err := release_config_lib.ReadReleaseConfigMaps(
+		release_config_lib.StringList(mapPaths), *targetRelease, false, *allowMissing,
+		release_config_lib.WithAllowUnknownNamespace(*allowUnknownNamespace))
+	if err != nil {
+		return err
+	}
+	return configs.WriteDiff(*outFile, *oldRelease, *newRelease, *format)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: release_config <subcommand> [args]")
+		os.Exit(1)
+	}
+	var err error
+	switch os.Args[1] {
+	case "diff":
+		err = diffMain(os.Args[2:])
+	default:
+		err = fmt.Errorf("unknown subcommand %q", os.Args[1])
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release_config_lib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"slices"
+)
+
+// bomFile is one entry in the BOM: a single file that was read while
+// generating the release configs, tagged with the config directory that
+// owns it, its content hash, and the release configs it contributed to.
+type bomFile struct {
+	Path           string   `json:"path"`
+	ConfigDirIndex int      `json:"config_dir_index"`
+	Sha256         string   `json:"sha256"`
+	ReleaseConfigs []string `json:"release_configs,omitempty"`
+}
+
+// bomArtifact is the manifest written by WriteBOM: a self-contained
+// fingerprint of the inputs that produced a given `all_release_configs-*.pb`.
+type bomArtifact struct {
+	TargetRelease string    `json:"target_release"`
+	ConfigDirs    []string  `json:"config_dirs"`
+	Files         []bomFile `json:"files"`
+}
+
+// WriteBOM writes a stable, sorted manifest of every file that contributed
+// to the generated release configs: every file in FilesUsedMap, every file
+// referenced by a FlagArtifact's Traces, and every ReleaseConfigContribution
+// path. This gives CI a cheap way to detect "same target release, different
+// flag results" regressions without re-parsing every textproto/scl file.
+func (configs *ReleaseConfigs) WriteBOM(outFile string) error {
+	consumedBy := make(map[string]map[string]bool)
+	addFile := func(path, release string) {
+		if path == "" {
+			return
+		}
+		if consumedBy[path] == nil {
+			consumedBy[path] = make(map[string]bool)
+		}
+		if release != "" {
+			consumedBy[path][release] = true
+		}
+	}
+
+	for path := range configs.FilesUsedMap {
+		addFile(path, "")
+	}
+	for name, config := range configs.ReleaseConfigs {
+		for path := range config.FilesUsedMap {
+			addFile(path, name)
+		}
+		if config.ReleaseConfigArtifact == nil {
+			continue
+		}
+		for _, fa := range config.ReleaseConfigArtifact.FlagArtifacts {
+			for _, trace := range fa.Traces {
+				addFile(trace.GetSource(), trace.GetReleaseConfig())
+			}
+		}
+	}
+	for _, m := range configs.ReleaseConfigMaps {
+		for name, contribution := range m.ReleaseConfigContributions {
+			addFile(contribution.path, name)
+		}
+	}
+
+	var paths []string
+	for path := range consumedBy {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+
+	var files []bomFile
+	for _, path := range paths {
+		dirIndex, err := configs.GetDirIndex(path)
+		if err != nil {
+			dirIndex = -1
+		}
+		sum, err := sha256File(path)
+		if err != nil {
+			return err
+		}
+		var releaseNames []string
+		for name := range consumedBy[path] {
+			releaseNames = append(releaseNames, name)
+		}
+		slices.Sort(releaseNames)
+		files = append(files, bomFile{
+			Path:           path,
+			ConfigDirIndex: dirIndex,
+			Sha256:         sum,
+			ReleaseConfigs: releaseNames,
+		})
+	}
+
+	bom := bomArtifact{
+		TargetRelease: configs.Artifact.ReleaseConfig.GetName(),
+		ConfigDirs:    configs.configDirs,
+		Files:         files,
+	}
+	data, err := json.MarshalIndent(bom, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(outFile, data, 0644)
+}
+
+func sha256File(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,189 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release_config_lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	rc_proto "android/soong/cmd/release_config/release_config_proto"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// FlagDiff is one flag whose resolved value differs between the two release
+// configs being diffed.
+type FlagDiff struct {
+	Name   string          `json:"name"`
+	ValueA *rc_proto.Value `json:"value_a"`
+	ValueB *rc_proto.Value `json:"value_b"`
+	// TraceA/TraceB are the winning Tracepoint (file + release config that
+	// set it) on each side.
+	TraceA *rc_proto.Tracepoint `json:"trace_a,omitempty"`
+	TraceB *rc_proto.Tracepoint `json:"trace_b,omitempty"`
+}
+
+// DiffArtifact is the result of diffing two release configs: every flag
+// whose value differs, plus any flag present in only one of the two.
+type DiffArtifact struct {
+	Flags   []*FlagDiff `json:"flags,omitempty"`
+	OnlyInA []string    `json:"only_in_a,omitempty"`
+	OnlyInB []string    `json:"only_in_b,omitempty"`
+}
+
+// Diff computes the symmetric difference in flag values between release
+// configs `a` and `b` (either of which may be an alias). The result also
+// records any flag present in only one of the two release configs.
+func (configs *ReleaseConfigs) Diff(a, b string) (*DiffArtifact, error) {
+	configA, err := configs.GetReleaseConfig(a)
+	if err != nil {
+		return nil, err
+	}
+	configB, err := configs.GetReleaseConfig(b)
+	if err != nil {
+		return nil, err
+	}
+	flagsA := flagArtifactsByName(configA.ReleaseConfigArtifact)
+	flagsB := flagArtifactsByName(configB.ReleaseConfigArtifact)
+
+	names := make(map[string]bool)
+	for name := range flagsA {
+		names[name] = true
+	}
+	for name := range flagsB {
+		names[name] = true
+	}
+	sortedNames := make([]string, 0, len(names))
+	for name := range names {
+		sortedNames = append(sortedNames, name)
+	}
+	slices.Sort(sortedNames)
+
+	diff := &DiffArtifact{}
+	for _, name := range sortedNames {
+		fa, okA := flagsA[name]
+		fb, okB := flagsB[name]
+		switch {
+		case okA && !okB:
+			diff.OnlyInA = append(diff.OnlyInA, name)
+		case okB && !okA:
+			diff.OnlyInB = append(diff.OnlyInB, name)
+		case !proto.Equal(fa.Value, fb.Value):
+			diff.Flags = append(diff.Flags, &FlagDiff{
+				Name:   name,
+				ValueA: fa.Value,
+				ValueB: fb.Value,
+				TraceA: lastTrace(fa.Traces),
+				TraceB: lastTrace(fb.Traces),
+			})
+		}
+	}
+	return diff, nil
+}
+
+// toProto converts a DiffArtifact to the rc_proto.ReleaseConfigDiffArtifact
+// message, for callers that want the "pb" serialization.
+func (diff *DiffArtifact) toProto() *rc_proto.ReleaseConfigDiffArtifact {
+	ret := &rc_proto.ReleaseConfigDiffArtifact{
+		OnlyInA: diff.OnlyInA,
+		OnlyInB: diff.OnlyInB,
+	}
+	for _, flag := range diff.Flags {
+		ret.Flags = append(ret.Flags, &rc_proto.FlagDiff{
+			Name:   proto.String(flag.Name),
+			ValueA: flag.ValueA,
+			ValueB: flag.ValueB,
+			TraceA: flag.TraceA,
+			TraceB: flag.TraceB,
+		})
+	}
+	return ret
+}
+
+// WriteDiff computes the diff between release configs `a` and `b` and writes
+// it to `outFile`. `format` is one of:
+//   - "text": a human-readable summary
+//   - "json": the DiffArtifact, JSON-encoded directly (no proto round-trip)
+//   - "pb": the serialized rc_proto.ReleaseConfigDiffArtifact message
+func (configs *ReleaseConfigs) WriteDiff(outFile, a, b, format string) error {
+	diff, err := configs.Diff(a, b)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case "text":
+		return os.WriteFile(outFile, []byte(renderDiffText(a, b, diff)), 0644)
+	case "json":
+		data, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(outFile, data, 0644)
+	case "pb":
+		return WriteMessage(outFile, diff.toProto())
+	default:
+		return fmt.Errorf("unsupported diff format %q, want one of text, json, pb", format)
+	}
+}
+
+func flagArtifactsByName(artifact *rc_proto.ReleaseConfigArtifact) map[string]*rc_proto.FlagArtifact {
+	ret := make(map[string]*rc_proto.FlagArtifact)
+	for _, fa := range artifact.FlagArtifacts {
+		ret[fa.GetName()] = fa
+	}
+	return ret
+}
+
+func lastTrace(traces []*rc_proto.Tracepoint) *rc_proto.Tracepoint {
+	if len(traces) == 0 {
+		return nil
+	}
+	return traces[len(traces)-1]
+}
+
+func renderDiffText(a, b string, diff *DiffArtifact) string {
+	var lines []string
+	lines = append(lines, fmt.Sprintf("Diff of %s vs %s:", a, b))
+	for _, flag := range diff.Flags {
+		lines = append(lines, fmt.Sprintf("  %s: %s=%s (%s) vs %s=%s (%s)",
+			flag.Name,
+			a, renderValue(flag.ValueA), renderTrace(flag.TraceA),
+			b, renderValue(flag.ValueB), renderTrace(flag.TraceB)))
+	}
+	if len(diff.OnlyInA) > 0 {
+		lines = append(lines, fmt.Sprintf("  only in %s: %s", a, strings.Join(diff.OnlyInA, " ")))
+	}
+	if len(diff.OnlyInB) > 0 {
+		lines = append(lines, fmt.Sprintf("  only in %s: %s", b, strings.Join(diff.OnlyInB, " ")))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func renderValue(v *rc_proto.Value) string {
+	if v == nil {
+		return "(unset)"
+	}
+	return v.String()
+}
+
+func renderTrace(t *rc_proto.Tracepoint) string {
+	if t == nil {
+		return "no trace"
+	}
+	return fmt.Sprintf("%s:%s", t.GetSource(), t.GetReleaseConfig())
+}
@@ -0,0 +1,96 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release_config_lib
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+
+	rc_proto "android/soong/cmd/release_config/release_config_proto"
+)
+
+// NamespaceArtifact is the resolved view of a single namespace: its
+// declaration, plus bookkeeping about which release config maps registered
+// it, so that duplicate and override validation can point at every
+// contributor.
+type NamespaceArtifact struct {
+	// The namespace declaration. If the namespace is declared in more than
+	// one release config map, this is the first declaration seen, with
+	// FrozenReleases merged in from every declaration.
+	Namespace *rc_proto.Namespace
+
+	// Directory index (into configDirs) of the map that first declared this
+	// namespace.
+	DeclarationIndex int
+
+	// Every namespaces/*.textproto file that declared this namespace.
+	DeclaredIn []string
+}
+
+// NamespaceFactory loads a single namespaces/*.textproto file.
+func NamespaceFactory(path string) *rc_proto.Namespace {
+	namespace := &rc_proto.Namespace{}
+	LoadMessage(path, namespace)
+	return namespace
+}
+
+// IsFrozenForRelease returns whether this namespace is frozen for `release`,
+// meaning that flag values in this namespace may no longer be overridden for
+// that release.
+func (n *NamespaceArtifact) IsFrozenForRelease(release string) bool {
+	return slices.Contains(n.Namespace.FrozenReleases, release)
+}
+
+// preloadNamespaces registers every namespace declared in any of
+// `configDirs`' namespaces/*.textproto files into configs.Namespaces.
+//
+// This must run before any flag_declarations are validated against
+// configs.Namespaces: flag_declarations in the first config directory may
+// reference a namespace that is only declared in the last one, and
+// namespace validation must not depend on map processing order.
+func (configs *ReleaseConfigs) preloadNamespaces(configDirs []string) error {
+	for idx, dir := range configDirs {
+		err := WalkTextprotoFiles(dir, "namespaces", func(path string, d fs.DirEntry, err error) error {
+			namespace := NamespaceFactory(path)
+			name := namespace.GetName()
+			if fmt.Sprintf("%s.textproto", name) != filepath.Base(path) {
+				return fmt.Errorf("%s incorrectly declares namespace %s", path, name)
+			}
+			configs.FilesUsedMap[path] = true
+			if existing, ok := configs.Namespaces[name]; ok {
+				if existing.Namespace.GetOwner() != namespace.GetOwner() ||
+					!slices.Equal(existing.Namespace.Containers, namespace.Containers) {
+					return fmt.Errorf("namespace %s declared with conflicting owner/containers in %s and %s",
+						name, existing.DeclaredIn[0], path)
+				}
+				existing.DeclaredIn = append(existing.DeclaredIn, path)
+				existing.Namespace.FrozenReleases = append(existing.Namespace.FrozenReleases, namespace.FrozenReleases...)
+			} else {
+				configs.Namespaces[name] = &NamespaceArtifact{
+					Namespace:        namespace,
+					DeclarationIndex: idx,
+					DeclaredIn:       []string{path},
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release_config_lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"slices"
+	"strings"
+
+	rc_proto "android/soong/cmd/release_config/release_config_proto"
+)
+
+// provenanceFlagEntry is one flag's section of the provenance report.
+type provenanceFlagEntry struct {
+	Name            string   `json:"name"`
+	DeclaringFile   string   `json:"declaring_file"`
+	DeclaringDirIdx int      `json:"declaring_dir_index"`
+	Traces          []string `json:"traces"`
+	Value           string   `json:"value"`
+	Workflow        string   `json:"workflow"`
+	Containers      []string `json:"containers"`
+	Redacted        bool     `json:"redacted"`
+	InheritancePath []string `json:"inheritance_path,omitempty"`
+}
+
+// WriteProvenanceReport writes a per-flag provenance report for the target
+// release config to `outFile`. `format` is one of "html", "md", or "json".
+//
+// For every flag, the report lists the file that declared it, the directory
+// index that declared it, the full ordered chain of files (and the release
+// config that owned each contribution) that set or overrode its value, the
+// final value, workflow, containers, redaction status, and -- for flags
+// whose final value came from an ancestor release config -- the inheritance
+// path from the release config that supplied it.
+func (configs *ReleaseConfigs) WriteProvenanceReport(outFile, format string) error {
+	releaseConfig := configs.Artifact.ReleaseConfig
+	entries, err := configs.provenanceEntries(releaseConfig)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(outFile, data, 0644)
+	case "md":
+		return os.WriteFile(outFile, []byte(renderProvenanceMd(releaseConfig.GetName(), entries)), 0644)
+	case "html":
+		return os.WriteFile(outFile, []byte(renderProvenanceHtml(releaseConfig.GetName(), entries)), 0644)
+	default:
+		return fmt.Errorf("unsupported provenance report format %q", format)
+	}
+}
+
+func (configs *ReleaseConfigs) provenanceEntries(releaseConfig *rc_proto.ReleaseConfigArtifact) ([]*provenanceFlagEntry, error) {
+	var entries []*provenanceFlagEntry
+	for _, fa := range releaseConfig.FlagArtifacts {
+		name := fa.GetName()
+		decl, ok := configs.FlagArtifacts[name]
+		if !ok {
+			return nil, fmt.Errorf("provenance report: no declaration found for flag %s", name)
+		}
+		var traces []string
+		for _, trace := range fa.Traces {
+			traces = append(traces, fmt.Sprintf("%s (%s)", trace.GetSource(), trace.GetReleaseConfig()))
+		}
+		// A flag only counts as inherited if the *winning* trace -- the one
+		// that set its final value -- came from an ancestor. An ancestor may
+		// have touched the flag earlier in the chain, but if the target
+		// release config itself overrode it last, that is not inheritance.
+		var inheritedFrom string
+		if winner := lastTrace(fa.Traces); winner != nil && winner.GetReleaseConfig() != releaseConfig.GetName() {
+			inheritedFrom = winner.GetReleaseConfig()
+		}
+		entry := &provenanceFlagEntry{
+			Name:            name,
+			DeclaringFile:   firstTraceSource(decl.Traces),
+			DeclaringDirIdx: decl.DeclarationIndex,
+			Traces:          traces,
+			Value:           fa.GetValue().String(),
+			Workflow:        decl.FlagDeclaration.GetWorkflow().String(),
+			Containers:      decl.FlagDeclaration.Containers,
+			Redacted:        fa.GetRedacted(),
+		}
+		if inheritedFrom != "" {
+			entry.InheritancePath = inheritancePathTo(configs, releaseConfig.GetName(), inheritedFrom)
+		}
+		entries = append(entries, entry)
+	}
+	slices.SortFunc(entries, func(a, b *provenanceFlagEntry) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return entries, nil
+}
+
+func firstTraceSource(traces []*rc_proto.Tracepoint) string {
+	if len(traces) == 0 {
+		return ""
+	}
+	return traces[0].GetSource()
+}
+
+// inheritancePathTo walks `from`'s InheritNames graph to find the chain of
+// release configs that leads to `to`, returning nil if there is no such
+// chain (e.g. `to` is `from` itself).
+func inheritancePathTo(configs *ReleaseConfigs, from, to string) []string {
+	visited := make(map[string]bool)
+	var walk func(name string) []string
+	walk = func(name string) []string {
+		if name == to {
+			return []string{name}
+		}
+		if visited[name] {
+			return nil
+		}
+		visited[name] = true
+		config, ok := configs.ReleaseConfigs[name]
+		if !ok {
+			return nil
+		}
+		for _, inherit := range config.InheritNames {
+			if path := walk(inherit); path != nil {
+				return append([]string{name}, path...)
+			}
+		}
+		return nil
+	}
+	path := walk(from)
+	if len(path) < 2 {
+		return nil
+	}
+	return path
+}
+
+func renderProvenanceMd(releaseName string, entries []*provenanceFlagEntry) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Flag provenance for %s\n\n", releaseName)
+	for _, e := range entries {
+		fmt.Fprintf(&b, "## %s\n\n", e.Name)
+		fmt.Fprintf(&b, "- Declared in: %s (config dir %d)\n", e.DeclaringFile, e.DeclaringDirIdx)
+		fmt.Fprintf(&b, "- Final value: `%s`\n", e.Value)
+		fmt.Fprintf(&b, "- Workflow: %s\n", e.Workflow)
+		fmt.Fprintf(&b, "- Containers: %s\n", strings.Join(e.Containers, ", "))
+		fmt.Fprintf(&b, "- Redacted: %t\n", e.Redacted)
+		if len(e.InheritancePath) > 0 {
+			fmt.Fprintf(&b, "- Inheritance path: %s\n", strings.Join(e.InheritancePath, " -> "))
+		}
+		fmt.Fprintf(&b, "- Traces:\n")
+		for _, t := range e.Traces {
+			fmt.Fprintf(&b, "  - %s\n", t)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func renderProvenanceHtml(releaseName string, entries []*provenanceFlagEntry) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<html><body>\n<h1>Flag provenance for %s</h1>\n", html.EscapeString(releaseName))
+	for _, e := range entries {
+		fmt.Fprintf(&b, "<h2>%s</h2>\n<ul>\n", html.EscapeString(e.Name))
+		fmt.Fprintf(&b, "<li>Declared in: %s (config dir %d)</li>\n", html.EscapeString(e.DeclaringFile), e.DeclaringDirIdx)
+		fmt.Fprintf(&b, "<li>Final value: <code>%s</code></li>\n", html.EscapeString(e.Value))
+		fmt.Fprintf(&b, "<li>Workflow: %s</li>\n", html.EscapeString(e.Workflow))
+		fmt.Fprintf(&b, "<li>Containers: %s</li>\n", html.EscapeString(strings.Join(e.Containers, ", ")))
+		fmt.Fprintf(&b, "<li>Redacted: %t</li>\n", e.Redacted)
+		if len(e.InheritancePath) > 0 {
+			fmt.Fprintf(&b, "<li>Inheritance path: %s</li>\n", html.EscapeString(strings.Join(e.InheritancePath, " -> ")))
+		}
+		b.WriteString("<li>Traces:<ul>\n")
+		for _, t := range e.Traces {
+			fmt.Fprintf(&b, "<li>%s</li>\n", html.EscapeString(t))
+		}
+		b.WriteString("</ul></li>\n</ul>\n")
+	}
+	b.WriteString("</body></html>\n")
+	return b.String()
+}
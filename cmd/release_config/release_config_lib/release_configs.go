@@ -42,6 +42,9 @@ type ReleaseConfigMap struct {
 
 	// Flags declared this directory's flag_declarations/*.textproto
 	FlagDeclarations []rc_proto.FlagDeclaration
+
+	// Namespaces declared in this directory's namespaces/*.textproto
+	Namespaces []rc_proto.Namespace
 }
 
 type ReleaseConfigDirMap map[string]int
@@ -57,6 +60,10 @@ type ReleaseConfigs struct {
 	// Dictionary of flag_name:FlagDeclaration, with no overrides applied.
 	FlagArtifacts FlagArtifacts
 
+	// Dictionary of namespace_name:NamespaceArtifact, registered from every
+	// release config map's namespaces/*.textproto directory.
+	Namespaces map[string]*NamespaceArtifact
+
 	// Generated release configs artifact
 	Artifact rc_proto.ReleaseConfigsArtifact
 
@@ -81,6 +88,13 @@ type ReleaseConfigs struct {
 	// case, we will substitute `trunk_staging` values, but the release
 	// config will not be in ALL_RELEASE_CONFIGS_FOR_PRODUCT.
 	allowMissing bool
+
+	// True if we should allow a flag declaration whose namespace is not
+	// registered in any namespaces/*.textproto (the declared namespace is
+	// kept as is), or that declares no namespace at all (it gets
+	// "android_UNKNOWN").  This is a staged rollout knob: it should be
+	// false once all trees have registered their namespaces.
+	allowUnknownNamespace bool
 }
 
 func (configs *ReleaseConfigs) WriteInheritanceGraph(outFile string) error {
@@ -174,11 +188,16 @@ func ReleaseConfigsFactory() (c *ReleaseConfigs) {
 	configs := ReleaseConfigs{
 		Aliases:              make(map[string]*string),
 		FlagArtifacts:        make(map[string]*FlagArtifact),
+		Namespaces:           make(map[string]*NamespaceArtifact),
 		ReleaseConfigs:       make(map[string]*ReleaseConfig),
 		releaseConfigMapsMap: make(map[string]*ReleaseConfigMap),
 		configDirs:           []string{},
 		configDirIndexes:     make(ReleaseConfigDirMap),
 		FilesUsedMap:         make(map[string]bool),
+		// Permissive by default, so a tree that has not yet added
+		// namespaces/*.textproto keeps building; WithAllowUnknownNamespace(false)
+		// opts a tree into strict enforcement once its namespaces are complete.
+		allowUnknownNamespace: true,
 	}
 	workflowManual := rc_proto.Workflow(rc_proto.Workflow_MANUAL)
 	releaseAconfigValueSets := FlagArtifact{
@@ -207,15 +226,31 @@ func (configs *ReleaseConfigs) GetSortedReleaseConfigs() (ret []*ReleaseConfig)
 	return ret
 }
 
-func ReleaseConfigMapFactory(protoPath string) (m *ReleaseConfigMap) {
+// matchesConfigFileName returns whether `path` is the file that a release
+// config or flag value named `name` must live in, regardless of whether the
+// directory uses the textproto or scl format.
+func matchesConfigFileName(path, name string) bool {
+	base := filepath.Base(path)
+	return base == name+".textproto" || base == name+".scl"
+}
+
+func ReleaseConfigMapFactory(protoPath string) (m *ReleaseConfigMap, err error) {
 	m = &ReleaseConfigMap{
 		path:                       protoPath,
 		ReleaseConfigContributions: make(map[string]*ReleaseConfigContribution),
 	}
 	if protoPath != "" {
-		LoadMessage(protoPath, &m.proto)
+		if strings.HasSuffix(protoPath, ".scl") {
+			scl, err := ReleaseConfigMapFactoryScl(protoPath)
+			if err != nil {
+				return nil, err
+			}
+			m.proto = *scl
+		} else {
+			LoadMessage(protoPath, &m.proto)
+		}
 	}
-	return m
+	return m, nil
 }
 
 // Find the top of the release config contribution directory.
@@ -247,20 +282,37 @@ func (configs *ReleaseConfigs) GetFlagValueDirectory(config *ReleaseConfig, flag
 // Return the (unsorted) release configs contributed to by `dir`.
 func EnumerateReleaseConfigs(dir string) ([]string, error) {
 	var ret []string
-	err := WalkTextprotoFiles(dir, "release_configs", func(path string, d fs.DirEntry, err error) error {
-		// Strip off the trailing `.textproto` from the name.
+	err := WalkConfigFiles(dir, "release_configs", func(path string, d fs.DirEntry, err error) error {
+		// Strip off the trailing `.textproto` or `.scl` extension from the name.
 		name := filepath.Base(path)
-		ret = append(ret, name[:len(name)-10])
+		ret = append(ret, strings.TrimSuffix(strings.TrimSuffix(name, ".textproto"), ".scl"))
 		return err
 	})
 	return ret, err
 }
 
+// resolveReleaseConfigMapPath returns the release_config_map file in `dir`,
+// preferring the textproto form when both are present.
+func resolveReleaseConfigMapPath(dir string) (string, error) {
+	textprotoPath := filepath.Join(dir, "release_config_map.textproto")
+	sclPath := filepath.Join(dir, "release_config_map.scl")
+	if _, err := os.Stat(textprotoPath); err == nil {
+		return textprotoPath, nil
+	}
+	if _, err := os.Stat(sclPath); err == nil {
+		return sclPath, nil
+	}
+	return "", fmt.Errorf("no release_config_map.textproto or release_config_map.scl in %s", dir)
+}
+
 func (configs *ReleaseConfigs) LoadReleaseConfigMap(path string, ConfigDirIndex int) error {
 	if _, err := os.Stat(path); err != nil {
 		return fmt.Errorf("%s does not exist\n", path)
 	}
-	m := ReleaseConfigMapFactory(path)
+	m, err := ReleaseConfigMapFactory(path)
+	if err != nil {
+		return err
+	}
 	if m.proto.DefaultContainers == nil {
 		return fmt.Errorf("Release config map %s lacks default_containers", path)
 	}
@@ -283,9 +335,29 @@ func (configs *ReleaseConfigs) LoadReleaseConfigMap(path string, ConfigDirIndex
 		}
 		configs.Aliases[name] = alias.Target
 	}
-	var err error
-	err = WalkTextprotoFiles(dir, "flag_declarations", func(path string, d fs.DirEntry, err error) error {
-		flagDeclaration := FlagDeclarationFactory(path)
+	// Namespaces are registered into configs.Namespaces by preloadNamespaces,
+	// called once up front across every config directory (see
+	// ReadReleaseConfigMaps) so that flag_declarations below can be
+	// validated regardless of map processing order. Here we only record
+	// this directory's namespaces for debugging.
+	err = WalkTextprotoFiles(dir, "namespaces", func(path string, d fs.DirEntry, err error) error {
+		m.Namespaces = append(m.Namespaces, *NamespaceFactory(path))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = WalkConfigFiles(dir, "flag_declarations", func(path string, d fs.DirEntry, err error) error {
+		var flagDeclaration *rc_proto.FlagDeclaration
+		var ferr error
+		if strings.HasSuffix(path, ".scl") {
+			flagDeclaration, ferr = FlagDeclarationFactoryScl(path)
+		} else {
+			flagDeclaration = FlagDeclarationFactory(path)
+		}
+		if ferr != nil {
+			return ferr
+		}
 		// Container must be specified.
 		if flagDeclaration.Containers == nil {
 			flagDeclaration.Containers = m.proto.DefaultContainers
@@ -297,9 +369,14 @@ func (configs *ReleaseConfigs) LoadReleaseConfigMap(path string, ConfigDirIndex
 			}
 		}
 
-		// TODO: once we have namespaces initialized, we can throw an error here.
 		if flagDeclaration.Namespace == nil {
+			if !configs.allowUnknownNamespace {
+				return fmt.Errorf("%s: flag %s does not declare a namespace", path, *flagDeclaration.Name)
+			}
 			flagDeclaration.Namespace = proto.String("android_UNKNOWN")
+		} else if _, ok := configs.Namespaces[*flagDeclaration.Namespace]; !ok && !configs.allowUnknownNamespace {
+			return fmt.Errorf("%s: flag %s declares unregistered namespace %s",
+				path, *flagDeclaration.Name, *flagDeclaration.Namespace)
 		}
 		// If the input didn't specify a value, create one (== UnspecifiedValue).
 		if flagDeclaration.Value == nil {
@@ -329,11 +406,19 @@ func (configs *ReleaseConfigs) LoadReleaseConfigMap(path string, ConfigDirIndex
 		return err
 	}
 
-	err = WalkTextprotoFiles(dir, "release_configs", func(path string, d fs.DirEntry, err error) error {
+	err = WalkConfigFiles(dir, "release_configs", func(path string, d fs.DirEntry, err error) error {
 		releaseConfigContribution := &ReleaseConfigContribution{path: path, DeclarationIndex: ConfigDirIndex}
-		LoadMessage(path, &releaseConfigContribution.proto)
+		if strings.HasSuffix(path, ".scl") {
+			contribution, ferr := ReleaseConfigContributionProtoFactoryScl(path)
+			if ferr != nil {
+				return ferr
+			}
+			releaseConfigContribution.proto = *contribution
+		} else {
+			LoadMessage(path, &releaseConfigContribution.proto)
+		}
 		name := *releaseConfigContribution.proto.Name
-		if fmt.Sprintf("%s.textproto", name) != filepath.Base(path) {
+		if !matchesConfigFileName(path, name) {
 			return fmt.Errorf("%s incorrectly declares release config %s", path, name)
 		}
 		if _, ok := configs.ReleaseConfigs[name]; !ok {
@@ -354,14 +439,28 @@ func (configs *ReleaseConfigs) LoadReleaseConfigMap(path string, ConfigDirIndex
 		}
 
 		// Only walk flag_values/{RELEASE} for defined releases.
-		err2 := WalkTextprotoFiles(dir, filepath.Join("flag_values", name), func(path string, d fs.DirEntry, err error) error {
-			flagValue := FlagValueFactory(path)
-			if fmt.Sprintf("%s.textproto", *flagValue.proto.Name) != filepath.Base(path) {
+		err2 := WalkConfigFiles(dir, filepath.Join("flag_values", name), func(path string, d fs.DirEntry, err error) error {
+			var flagValue FlagValue
+			if strings.HasSuffix(path, ".scl") {
+				fv, ferr := FlagValueFactoryScl(path)
+				if ferr != nil {
+					return ferr
+				}
+				flagValue = FlagValue{path: path, proto: *fv}
+			} else {
+				flagValue = FlagValueFactory(path)
+			}
+			if !matchesConfigFileName(path, *flagValue.proto.Name) {
 				return fmt.Errorf("%s incorrectly sets value for flag %s", path, *flagValue.proto.Name)
 			}
 			if *flagValue.proto.Name == "RELEASE_ACONFIG_VALUE_SETS" {
 				return fmt.Errorf("%s: %s is a reserved build flag", path, *flagValue.proto.Name)
 			}
+			// Frozen-namespace overrides are validated later, by
+			// validateFrozenNamespaces, once every config directory's
+			// flag_declarations have been loaded: the flag this value
+			// overrides may not be declared yet if it lives in a directory
+			// later than this one.
 			config.FilesUsedMap[path] = true
 			releaseConfigContribution.FlagValues = append(releaseConfigContribution.FlagValues, flagValue)
 			return nil
@@ -386,8 +485,13 @@ func (configs *ReleaseConfigs) LoadReleaseConfigMap(path string, ConfigDirIndex
 
 func (configs *ReleaseConfigs) GetReleaseConfig(name string) (*ReleaseConfig, error) {
 	trace := []string{name}
+	visited := map[string]bool{name: true}
 	for target, ok := configs.Aliases[name]; ok; target, ok = configs.Aliases[name] {
 		name = *target
+		if visited[name] {
+			return nil, fmt.Errorf("Cyclic alias declarations found.  Trace=%v", append(trace, name))
+		}
+		visited[name] = true
 		trace = append(trace, name)
 	}
 	if config, ok := configs.ReleaseConfigs[name]; ok {
@@ -412,6 +516,9 @@ func (configs *ReleaseConfigs) GetAllReleaseNames() []string {
 }
 
 func (configs *ReleaseConfigs) GenerateReleaseConfigs(targetRelease string) error {
+	if err := configs.validateGraphs(); err != nil {
+		return err
+	}
 	otherNames := make(map[string][]string)
 	for aliasName, aliasTarget := range configs.Aliases {
 		if _, ok := configs.ReleaseConfigs[aliasName]; ok {
@@ -436,6 +543,13 @@ func (configs *ReleaseConfigs) GenerateReleaseConfigs(targetRelease string) erro
 		}
 	}
 
+	// PriorStagesMap is only populated by GenerateReleaseConfig above, so
+	// prior-stage validation must run after that loop, not as part of
+	// validateGraphs.
+	if err := configs.validatePriorStages(); err != nil {
+		return err
+	}
+
 	releaseConfig, err := configs.GetReleaseConfig(targetRelease)
 	if err != nil {
 		return err
@@ -457,11 +571,38 @@ func (configs *ReleaseConfigs) GenerateReleaseConfigs(targetRelease string) erro
 			}
 			return ret
 		}(),
+		Namespaces: func() map[string]*rc_proto.NamespaceArtifact {
+			ret := make(map[string]*rc_proto.NamespaceArtifact)
+			for k, v := range configs.Namespaces {
+				ret[k] = &rc_proto.NamespaceArtifact{
+					Namespace:  v.Namespace,
+					DeclaredIn: v.DeclaredIn,
+				}
+			}
+			return ret
+		}(),
 	}
 	return nil
 }
 
-func ReadReleaseConfigMaps(releaseConfigMapPaths StringList, targetRelease string, useBuildVar, allowMissing bool) (*ReleaseConfigs, error) {
+// ReadOption configures optional behavior of ReadReleaseConfigMaps. Adding a
+// new knob as a ReadOption, rather than a new positional parameter, keeps
+// existing callers compiling unchanged.
+type ReadOption func(*ReleaseConfigs)
+
+// WithAllowUnknownNamespace allows a flag declaration whose namespace is not
+// registered in any namespaces/*.textproto (the declared namespace is kept as
+// is), or that declares no namespace at all (it gets "android_UNKNOWN"). This
+// is a staged-rollout knob for trees that have not yet
+// registered all of their namespaces; it corresponds to the
+// `--allow-unknown-namespace` command-line flag.
+func WithAllowUnknownNamespace(allow bool) ReadOption {
+	return func(configs *ReleaseConfigs) {
+		configs.allowUnknownNamespace = allow
+	}
+}
+
+func ReadReleaseConfigMaps(releaseConfigMapPaths StringList, targetRelease string, useBuildVar, allowMissing bool, opts ...ReadOption) (*ReleaseConfigs, error) {
 	var err error
 
 	if len(releaseConfigMapPaths) == 0 {
@@ -479,10 +620,15 @@ func ReadReleaseConfigMaps(releaseConfigMapPaths StringList, targetRelease strin
 
 	configs := ReleaseConfigsFactory()
 	configs.allowMissing = allowMissing
+	for _, opt := range opts {
+		opt(configs)
+	}
+
+	// First pass: resolve the ordered, deduplicated list of config
+	// directories, without loading anything from them yet.
 	mapsRead := make(map[string]bool)
 	var idx int
 	for _, releaseConfigMapPath := range releaseConfigMapPaths {
-		// Maintain an ordered list of release config directories.
 		configDir := filepath.Dir(releaseConfigMapPath)
 		if mapsRead[configDir] {
 			continue
@@ -490,13 +636,30 @@ func ReadReleaseConfigMaps(releaseConfigMapPaths StringList, targetRelease strin
 		mapsRead[configDir] = true
 		configs.configDirIndexes[configDir] = idx
 		configs.configDirs = append(configs.configDirs, configDir)
-		// Force the path to be the textproto path, so that both the scl and textproto formats can coexist.
-		releaseConfigMapPath = filepath.Join(configDir, "release_config_map.textproto")
-		err = configs.LoadReleaseConfigMap(releaseConfigMapPath, idx)
+		idx += 1
+	}
+
+	// Second pass: register every namespace from every config directory
+	// before validating any flag_declarations against them. Namespace
+	// validation must not depend on map processing order: a flag declared
+	// in the first config directory may reference a namespace that is only
+	// declared in the last one.
+	if err := configs.preloadNamespaces(configs.configDirs); err != nil {
+		return nil, err
+	}
+
+	// Third pass: load each release config map, with all namespaces already
+	// known.
+	for i, configDir := range configs.configDirs {
+		// Prefer the textproto form when both are present, so a directory can be
+		// migrated to scl one release config map at a time.
+		releaseConfigMapPath, err := resolveReleaseConfigMapPath(configDir)
 		if err != nil {
 			return nil, err
 		}
-		idx += 1
+		if err := configs.LoadReleaseConfigMap(releaseConfigMapPath, i); err != nil {
+			return nil, err
+		}
 	}
 
 	// Now that we have all of the release config maps, can meld them and generate the artifacts.
@@ -0,0 +1,370 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release_config_lib
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	rc_proto "android/soong/cmd/release_config/release_config_proto"
+
+	"go.starlark.net/starlark"
+	"google.golang.org/protobuf/proto"
+)
+
+// WalkConfigFiles is WalkTextprotoFiles, generalized to also accept the `.scl`
+// form of a release config map's flag_declarations, flag_values, and
+// release_configs directories. A single directory must not mix the two
+// formats, but different directories in the same release config map may use
+// different formats while a tree migrates from one to the other.
+func WalkConfigFiles(root, subdir string, action func(path string, d fs.DirEntry, err error) error) error {
+	dir := filepath.Join(root, subdir)
+	if _, err := os.Stat(dir); err != nil {
+		// No such directory is not an error -- it is just empty.
+		return nil
+	}
+	var sclCount, textprotoCount int
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		switch filepath.Ext(path) {
+		case ".scl":
+			sclCount++
+		case ".textproto":
+			textprotoCount++
+		default:
+			return nil
+		}
+		return action(path, d, nil)
+	})
+	if err != nil {
+		return err
+	}
+	if sclCount > 0 && textprotoCount > 0 {
+		return fmt.Errorf("%s contains both .scl and .textproto files -- pick one", dir)
+	}
+	return nil
+}
+
+// sclThread returns a fresh starlark.Thread for evaluating a single release
+// config input file. Each file is evaluated in isolation: we do not support
+// `load()`, since the fixed schema below is the entire language surface we
+// expose.
+func sclThread(path string) *starlark.Thread {
+	return &starlark.Thread{
+		Name: path,
+		Load: func(_ *starlark.Thread, module string) (starlark.StringDict, error) {
+			return nil, fmt.Errorf("%s: load() is not supported in release config .scl files", module)
+		},
+	}
+}
+
+func sclStringArg(v starlark.Value) (string, error) {
+	s, ok := starlark.AsString(v)
+	if !ok {
+		return "", fmt.Errorf("expected string, got %s", v.Type())
+	}
+	return s, nil
+}
+
+func sclStringListArg(v starlark.Value) ([]string, error) {
+	iterable, ok := v.(starlark.Iterable)
+	if !ok {
+		return nil, fmt.Errorf("expected list of strings, got %s", v.Type())
+	}
+	var ret []string
+	iter := iterable.Iterate()
+	defer iter.Done()
+	var item starlark.Value
+	for iter.Next(&item) {
+		s, err := sclStringArg(item)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, s)
+	}
+	return ret, nil
+}
+
+func sclValueArg(v starlark.Value) (*rc_proto.Value, error) {
+	switch val := v.(type) {
+	case starlark.Bool:
+		return &rc_proto.Value{Val: &rc_proto.Value_BoolValue{bool(val)}}, nil
+	case starlark.String:
+		return &rc_proto.Value{Val: &rc_proto.Value_StringValue{string(val)}}, nil
+	case starlark.NoneType:
+		return &rc_proto.Value{Val: &rc_proto.Value_UnspecifiedValue{false}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported value type %s", v.Type())
+	}
+}
+
+func sclWorkflowArg(v starlark.Value) (*rc_proto.Workflow, error) {
+	s, err := sclStringArg(v)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.ToUpper(s)
+	num, ok := rc_proto.Workflow_value[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown workflow %q", s)
+	}
+	workflow := rc_proto.Workflow(num)
+	return &workflow, nil
+}
+
+// FlagDeclarationFactoryScl parses a flag_declarations/*.scl file, which must
+// contain exactly one call to the `flag_declaration(...)` builtin, and
+// returns the same *rc_proto.FlagDeclaration that FlagDeclarationFactory
+// returns for the textproto form.
+func FlagDeclarationFactoryScl(path string) (*rc_proto.FlagDeclaration, error) {
+	var result *rc_proto.FlagDeclaration
+	declare := func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		if result != nil {
+			return nil, fmt.Errorf("only one flag_declaration() call is allowed per file")
+		}
+		if len(args) > 0 {
+			return nil, fmt.Errorf("flag_declaration() only accepts keyword arguments")
+		}
+		fd := &rc_proto.FlagDeclaration{}
+		for _, kv := range kwargs {
+			key, _ := sclStringArg(kv[0])
+			var err error
+			switch key {
+			case "name":
+				var s string
+				if s, err = sclStringArg(kv[1]); err == nil {
+					fd.Name = proto.String(s)
+				}
+			case "namespace":
+				var s string
+				if s, err = sclStringArg(kv[1]); err == nil {
+					fd.Namespace = proto.String(s)
+				}
+			case "description":
+				var s string
+				if s, err = sclStringArg(kv[1]); err == nil {
+					fd.Description = proto.String(s)
+				}
+			case "bug":
+				var l []string
+				if l, err = sclStringListArg(kv[1]); err == nil {
+					fd.Bugs = l
+				}
+			case "workflow":
+				var w *rc_proto.Workflow
+				if w, err = sclWorkflowArg(kv[1]); err == nil {
+					fd.Workflow = w
+				}
+			case "containers":
+				var l []string
+				if l, err = sclStringListArg(kv[1]); err == nil {
+					fd.Containers = l
+				}
+			case "value":
+				var val *rc_proto.Value
+				if val, err = sclValueArg(kv[1]); err == nil {
+					fd.Value = val
+				}
+			default:
+				err = fmt.Errorf("unknown flag_declaration() argument %q", key)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", key, err)
+			}
+		}
+		if fd.Name == nil {
+			return nil, fmt.Errorf("flag_declaration() requires a name")
+		}
+		result = fd
+		return starlark.None, nil
+	}
+	predeclared := starlark.StringDict{
+		"flag_declaration": starlark.NewBuiltin("flag_declaration", declare),
+	}
+	if _, err := starlark.ExecFile(sclThread(path), path, nil, predeclared); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if result == nil {
+		return nil, fmt.Errorf("%s: missing flag_declaration(...) call", path)
+	}
+	return result, nil
+}
+
+// FlagValueFactoryScl parses a flag_values/{RELEASE}/*.scl file, which must
+// contain exactly one call to the `flag_value(...)` builtin, and returns the
+// same *rc_proto.FlagValue that FlagValueFactory returns for the textproto
+// form.
+func FlagValueFactoryScl(path string) (*rc_proto.FlagValue, error) {
+	var result *rc_proto.FlagValue
+	declare := func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		if result != nil {
+			return nil, fmt.Errorf("only one flag_value() call is allowed per file")
+		}
+		if len(args) > 0 {
+			return nil, fmt.Errorf("flag_value() only accepts keyword arguments")
+		}
+		fv := &rc_proto.FlagValue{}
+		for _, kv := range kwargs {
+			key, _ := sclStringArg(kv[0])
+			var err error
+			switch key {
+			case "name":
+				var s string
+				if s, err = sclStringArg(kv[1]); err == nil {
+					fv.Name = proto.String(s)
+				}
+			case "value":
+				var val *rc_proto.Value
+				if val, err = sclValueArg(kv[1]); err == nil {
+					fv.Value = val
+				}
+			case "redacted":
+				b, ok := kv[1].(starlark.Bool)
+				if !ok {
+					err = fmt.Errorf("expected bool, got %s", kv[1].Type())
+				} else {
+					fv.Redacted = proto.Bool(bool(b))
+				}
+			default:
+				err = fmt.Errorf("unknown flag_value() argument %q", key)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", key, err)
+			}
+		}
+		if fv.Name == nil {
+			return nil, fmt.Errorf("flag_value() requires a name")
+		}
+		result = fv
+		return starlark.None, nil
+	}
+	predeclared := starlark.StringDict{
+		"flag_value": starlark.NewBuiltin("flag_value", declare),
+	}
+	if _, err := starlark.ExecFile(sclThread(path), path, nil, predeclared); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if result == nil {
+		return nil, fmt.Errorf("%s: missing flag_value(...) call", path)
+	}
+	return result, nil
+}
+
+// ReleaseConfigContributionProtoFactoryScl parses a release_configs/*.scl
+// file, which must contain exactly one call to the `release_config(...)`
+// builtin, and returns the same *rc_proto.ReleaseConfigContribution that
+// LoadMessage returns for the textproto form.
+func ReleaseConfigContributionProtoFactoryScl(path string) (*rc_proto.ReleaseConfigContribution, error) {
+	var result *rc_proto.ReleaseConfigContribution
+	declare := func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		if result != nil {
+			return nil, fmt.Errorf("only one release_config() call is allowed per file")
+		}
+		if len(args) > 0 {
+			return nil, fmt.Errorf("release_config() only accepts keyword arguments")
+		}
+		rc := &rc_proto.ReleaseConfigContribution{}
+		for _, kv := range kwargs {
+			key, _ := sclStringArg(kv[0])
+			var err error
+			switch key {
+			case "name":
+				var s string
+				if s, err = sclStringArg(kv[1]); err == nil {
+					rc.Name = proto.String(s)
+				}
+			case "inherits":
+				rc.Inherits, err = sclStringListArg(kv[1])
+			case "aconfig_flags_only":
+				b, ok := kv[1].(starlark.Bool)
+				if !ok {
+					err = fmt.Errorf("expected bool, got %s", kv[1].Type())
+				} else {
+					rc.AconfigFlagsOnly = proto.Bool(bool(b))
+				}
+			default:
+				err = fmt.Errorf("unknown release_config() argument %q", key)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", key, err)
+			}
+		}
+		if rc.Name == nil {
+			return nil, fmt.Errorf("release_config() requires a name")
+		}
+		result = rc
+		return starlark.None, nil
+	}
+	predeclared := starlark.StringDict{
+		"release_config": starlark.NewBuiltin("release_config", declare),
+	}
+	if _, err := starlark.ExecFile(sclThread(path), path, nil, predeclared); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if result == nil {
+		return nil, fmt.Errorf("%s: missing release_config(...) call", path)
+	}
+	return result, nil
+}
+
+// ReleaseConfigMapFactoryScl parses a release_config_map.scl file into the
+// same *rc_proto.ReleaseConfigMap that LoadMessage populates from the
+// textproto form. Only `default_containers` and any number of `alias(...)`
+// calls are recognized at this level; per-flag and per-release-config data
+// lives in the flag_declarations, flag_values, and release_configs
+// directories alongside it.
+func ReleaseConfigMapFactoryScl(path string) (*rc_proto.ReleaseConfigMap, error) {
+	m := &rc_proto.ReleaseConfigMap{}
+	setDefaults := func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		for _, kv := range kwargs {
+			key, _ := sclStringArg(kv[0])
+			switch key {
+			case "default_containers":
+				containers, err := sclStringListArg(kv[1])
+				if err != nil {
+					return nil, fmt.Errorf("default_containers: %v", err)
+				}
+				m.DefaultContainers = containers
+			default:
+				return nil, fmt.Errorf("unknown release_config_map() argument %q", key)
+			}
+		}
+		return starlark.None, nil
+	}
+	declareAlias := func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var name, target string
+		if err := starlark.UnpackArgs("alias", args, kwargs, "name", &name, "target", &target); err != nil {
+			return nil, err
+		}
+		m.Aliases = append(m.Aliases, &rc_proto.ReleaseAlias{
+			Name:   proto.String(name),
+			Target: proto.String(target),
+		})
+		return starlark.None, nil
+	}
+	predeclared := starlark.StringDict{
+		"release_config_map": starlark.NewBuiltin("release_config_map", setDefaults),
+		"alias":              starlark.NewBuiltin("alias", declareAlias),
+	}
+	if _, err := starlark.ExecFile(sclThread(path), path, nil, predeclared); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return m, nil
+}
@@ -0,0 +1,191 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release_config_lib
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// findCycles performs a depth-first search over the directed graph described
+// by `edges` (node -> its out-edges) and returns every cycle it finds, each
+// as the ordered list of nodes from the start of the cycle back to itself.
+// Nodes are visited in sorted order so the result is reproducible.
+func findCycles(edges map[string][]string) [][]string {
+	var cycles [][]string
+	const (
+		unvisited = iota
+		inProgress
+		done
+	)
+	state := make(map[string]int)
+	var path []string
+	var visit func(node string)
+	visit = func(node string) {
+		state[node] = inProgress
+		path = append(path, node)
+		for _, next := range edges[node] {
+			switch state[next] {
+			case unvisited:
+				visit(next)
+			case inProgress:
+				for i, n := range path {
+					if n == next {
+						cycle := append(append([]string{}, path[i:]...), next)
+						cycles = append(cycles, cycle)
+						break
+					}
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[node] = done
+	}
+	var nodes []string
+	for node := range edges {
+		nodes = append(nodes, node)
+	}
+	slices.Sort(nodes)
+	for _, node := range nodes {
+		if state[node] == unvisited {
+			visit(node)
+		}
+	}
+	return cycles
+}
+
+func formatCycles(cycles [][]string) string {
+	var lines []string
+	for _, cycle := range cycles {
+		lines = append(lines, "  "+strings.Join(cycle, " -> "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatCyclesWithFiles is formatCycles, with each release config in the
+// cycle annotated with the textproto/scl files that contributed to it, so
+// users can find the offending file quickly.
+func formatCyclesWithFiles(configs *ReleaseConfigs, cycles [][]string) string {
+	var lines []string
+	for _, cycle := range cycles {
+		lines = append(lines, "  "+strings.Join(cycle, " -> "))
+		for _, name := range cycle {
+			config, ok := configs.ReleaseConfigs[name]
+			if !ok {
+				continue
+			}
+			var files []string
+			for f := range config.FilesUsedMap {
+				files = append(files, f)
+			}
+			slices.Sort(files)
+			lines = append(lines, fmt.Sprintf("    %s: %s", name, strings.Join(files, ", ")))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// validateAliasesAcyclic errors out, reporting every cycle found, if any
+// chain of aliases loops back on itself.
+func (configs *ReleaseConfigs) validateAliasesAcyclic() error {
+	edges := make(map[string][]string)
+	for name, target := range configs.Aliases {
+		edges[name] = []string{*target}
+	}
+	if cycles := findCycles(edges); len(cycles) > 0 {
+		return fmt.Errorf("cyclic alias declarations found:\n%s", formatCycles(cycles))
+	}
+	return nil
+}
+
+// validateInheritanceAcyclic errors out, reporting every cycle found, if any
+// release config's InheritNames chain loops back on itself.
+func (configs *ReleaseConfigs) validateInheritanceAcyclic() error {
+	edges := make(map[string][]string)
+	for name, config := range configs.ReleaseConfigs {
+		edges[name] = config.InheritNames
+	}
+	if cycles := findCycles(edges); len(cycles) > 0 {
+		return fmt.Errorf("cyclic release config inheritance found:\n%s", formatCyclesWithFiles(configs, cycles))
+	}
+	return nil
+}
+
+// validatePriorStages errors out if any release config's PriorStagesMap
+// refers to a release config that does not exist, or if the prior-stage
+// edges (used to render the dashed advancement edges in
+// WriteInheritanceGraph) form a cycle.
+func (configs *ReleaseConfigs) validatePriorStages() error {
+	edges := make(map[string][]string)
+	for name, config := range configs.ReleaseConfigs {
+		for prior := range config.PriorStagesMap {
+			if _, ok := configs.ReleaseConfigs[prior]; !ok {
+				return fmt.Errorf("release config %s declares prior stage %s, which does not exist", name, prior)
+			}
+			edges[prior] = append(edges[prior], name)
+		}
+	}
+	if cycles := findCycles(edges); len(cycles) > 0 {
+		return fmt.Errorf("cyclic prior-stage declarations found:\n%s", formatCyclesWithFiles(configs, cycles))
+	}
+	return nil
+}
+
+// validateFrozenNamespaces errors out if any release config overrides a
+// flag whose namespace is frozen for that release.
+//
+// This must run after every config directory's flag_declarations and
+// flag_values have been loaded: a flag_values/{RELEASE} override in one
+// config directory may target a flag whose flag_declaration only appears in
+// a later directory, so configs.FlagArtifacts is not complete until
+// ReadReleaseConfigMaps has finished its third pass.
+func (configs *ReleaseConfigs) validateFrozenNamespaces() error {
+	for _, config := range configs.ReleaseConfigs {
+		for _, contribution := range config.Contributions {
+			for _, flagValue := range contribution.FlagValues {
+				name := flagValue.proto.GetName()
+				flagArtifact, ok := configs.FlagArtifacts[name]
+				if !ok {
+					continue
+				}
+				ns, ok := configs.Namespaces[flagArtifact.FlagDeclaration.GetNamespace()]
+				if ok && ns.IsFrozenForRelease(config.Name) {
+					return fmt.Errorf("%s: namespace %s is frozen for release %s, %s may not be overridden",
+						flagValue.path, ns.Namespace.GetName(), config.Name, name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validateGraphs runs the validations that only need the release
+// config maps to have been loaded: aliases, release config inheritance, and
+// frozen-namespace overrides. validatePriorStages runs separately, after
+// GenerateReleaseConfig has populated PriorStagesMap for every release
+// config.
+func (configs *ReleaseConfigs) validateGraphs() error {
+	if err := configs.validateAliasesAcyclic(); err != nil {
+		return err
+	}
+	if err := configs.validateInheritanceAcyclic(); err != nil {
+		return err
+	}
+	if err := configs.validateFrozenNamespaces(); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2024 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package release_config_lib
+
+import (
+	"testing"
+)
+
+func TestFindCycles(t *testing.T) {
+	tests := []struct {
+		name       string
+		edges      map[string][]string
+		wantCycles [][]string
+	}{
+		{
+			name:       "no edges",
+			edges:      map[string][]string{},
+			wantCycles: nil,
+		},
+		{
+			name: "acyclic",
+			edges: map[string][]string{
+				"a": {"b"},
+				"b": {"c"},
+				"c": {},
+			},
+			wantCycles: nil,
+		},
+		{
+			name: "self loop",
+			edges: map[string][]string{
+				"a": {"a"},
+			},
+			wantCycles: [][]string{{"a", "a"}},
+		},
+		{
+			name: "two node cycle",
+			edges: map[string][]string{
+				"a": {"b"},
+				"b": {"a"},
+			},
+			wantCycles: [][]string{{"a", "b", "a"}},
+		},
+		{
+			name: "multiple independent cycles are all reported",
+			edges: map[string][]string{
+				"a": {"b"},
+				"b": {"a"},
+				"c": {"d"},
+				"d": {"c"},
+			},
+			wantCycles: [][]string{
+				{"a", "b", "a"},
+				{"c", "d", "c"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := findCycles(tt.edges)
+			if len(got) != len(tt.wantCycles) {
+				t.Fatalf("findCycles(%v) = %v, want %v", tt.edges, got, tt.wantCycles)
+			}
+			for i, cycle := range got {
+				want := tt.wantCycles[i]
+				if len(cycle) != len(want) {
+					t.Fatalf("findCycles(%v)[%d] = %v, want %v", tt.edges, i, cycle, want)
+				}
+				for j, node := range cycle {
+					if node != want[j] {
+						t.Fatalf("findCycles(%v)[%d] = %v, want %v", tt.edges, i, cycle, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestValidateAliasesAcyclic(t *testing.T) {
+	target := func(s string) *string { return &s }
+	configs := &ReleaseConfigs{
+		Aliases: map[string]*string{
+			"a": target("b"),
+			"b": target("a"),
+		},
+	}
+	if err := configs.validateAliasesAcyclic(); err == nil {
+		t.Errorf("validateAliasesAcyclic() = nil, want error for cyclic aliases a -> b -> a")
+	}
+
+	configs = &ReleaseConfigs{
+		Aliases: map[string]*string{
+			"a": target("b"),
+		},
+	}
+	if err := configs.validateAliasesAcyclic(); err != nil {
+		t.Errorf("validateAliasesAcyclic() = %v, want nil for acyclic aliases", err)
+	}
+}